@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/iamcredentials/v1"
@@ -19,26 +24,97 @@ import (
 	"github.com/vinm0/gmail-handler/pkg/auth"
 	"github.com/vinm0/gmail-handler/pkg/constants"
 	"github.com/vinm0/gmail-handler/pkg/email"
+	"github.com/vinm0/gmail-handler/pkg/queue"
+	"github.com/vinm0/gmail-handler/pkg/suppress"
 )
 
 // Environment Variable Keys
 const (
-	EnvDelegatedUser = "DELEGATED_USER_EMAIL"    // e.g. admin@ or notifications@
-	EnvFunctionSA    = "FUNCTION_IDENTITY_EMAIL" // The Cloud Function's Service Account
+	EnvDelegatedUser  = "DELEGATED_USER_EMAIL"    // e.g. admin@ or notifications@
+	EnvFunctionSA     = "FUNCTION_IDENTITY_EMAIL" // The Cloud Function's Service Account
+	EnvPubSubProject  = "PUBSUB_PROJECT_ID"       // GCP project hosting the send-queue topic
+	EnvPubSubTopic    = "PUBSUB_TOPIC_ID"         // Topic HandleEmail publishes to and Eventarc drains
+	EnvTokenLifetime  = "TOKEN_LIFETIME_SECONDS"  // Override for KeylessTokenSource.Lifetime, at most 1h (see MaxTokenLifetime; larger values make Token fail) -- does NOT reduce mint rate for batch jobs beyond that
+	EnvUnsubscribeURL = "UNSUBSCRIBE_BASE_URL"    // Base URL this function is reachable at, for minted List-Unsubscribe links
 
 	// Safety Limits
 	MaxTotalSizeMB = 20
 )
 
+// tokenLifetime reads EnvTokenLifetime and returns the requested token
+// lifetime, or 0 (KeylessTokenSource's default) if unset or unparsable. This
+// lets callers tune the lifetime within KeylessTokenSource's bounds instead of
+// always minting a flat hour. The value is not clamped here: one outside
+// [MinTokenLifetime, MaxTokenLifetime] makes KeylessTokenSource.Token return
+// an error. It cannot be used to cut the mint rate for long batch jobs below
+// once an hour: MaxTokenLifetime bounds it at 1h because DWD
+// impersonation only works through the SignJwt+exchange flow, which the
+// token endpoint only honors for up to an hour regardless of the requested
+// exp.
+func tokenLifetime() time.Duration {
+	raw := os.Getenv(EnvTokenLifetime)
+	if raw == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil {
+		slog.Warn("ignoring invalid token lifetime", "value", raw, "error", err)
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 func init() {
-	functions.HTTP("HandleEmail", HandleEmail)
+	functions.HTTP("HandleEmail", newRouter().ServeHTTP)
+	functions.CloudEvent("HandleEmailEvent", HandleEmailEvent)
 }
 
 // Global service client to reuse across warm starts
-var gmailService *gmail.Service
+var (
+	gmailServiceMu sync.Mutex
+	gmailService   *gmail.Service
+)
+
+// Global IAM Credentials client, reused across warm starts for both Gmail
+// auth (SignJwt) and unsubscribe token signing (SignBlob)
+var (
+	iamClientMu sync.Mutex
+	iamClient   *iamcredentials.Service
+)
+
+// Global send queue (lazily built), dedupe store, and suppression list (both
+// built at package init), reused across warm starts
+var (
+	sendQueueMu sync.Mutex
+	sendQueue   queue.Queue
+)
+var dedupe queue.Dedupe = queue.NewInMemoryDedupe()
+var suppressor suppress.Suppressor = suppress.NewInMemory()
+
+// initIAMClient creates the IAM Credentials client (Standard ADC) used to
+// authenticate as the Cloud Function itself. Guarded by iamClientMu so
+// concurrent invocations on the same warm instance don't race on iamClient.
+func initIAMClient(ctx context.Context) error {
+	iamClientMu.Lock()
+	defer iamClientMu.Unlock()
+
+	if iamClient != nil {
+		return nil
+	}
+
+	client, err := iamcredentials.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create iam client: %v", err)
+	}
+	iamClient = client
+	return nil
+}
 
 // initGmailService performs the Keyless Domain-Wide Delegation
 func initGmailService(ctx context.Context) error {
+	gmailServiceMu.Lock()
+	defer gmailServiceMu.Unlock()
+
 	if gmailService != nil {
 		return nil
 	}
@@ -52,10 +128,8 @@ func initGmailService(ctx context.Context) error {
 	}
 
 	// 2. Initialize IAM Credentials Client (Standard ADC)
-	// This client authenticates as the Cloud Function itself
-	iamClient, err := iamcredentials.NewService(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to create iam client: %v", err)
+	if err := initIAMClient(ctx); err != nil {
+		return err
 	}
 
 	// 3. Create the Keyless Token Source
@@ -65,6 +139,7 @@ func initGmailService(ctx context.Context) error {
 		DelegateEmail:       delegatedUser,
 		Scopes:              []string{gmail.GmailSendScope, gmail.GmailModifyScope},
 		IamClient:           iamClient,
+		Lifetime:            tokenLifetime(),
 	}
 
 	// 4. Create Gmail Service using the custom TokenSource
@@ -76,44 +151,57 @@ func initGmailService(ctx context.Context) error {
 	return nil
 }
 
-func HandleEmail(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+// initSendQueue builds the Pub/Sub-backed send queue from the environment.
+func initSendQueue(ctx context.Context) error {
+	sendQueueMu.Lock()
+	defer sendQueueMu.Unlock()
 
-	// --- 0. Routing / Path Validation ---
-	if r.URL.Path == "/health" {
-		w.Header().Set(constants.HTTPContentType, constants.HTTPAppJSON)
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"status":"healthy","service":"gmail-handler"}`)
-		return
+	if sendQueue != nil {
+		return nil
 	}
 
-	if r.URL.Path != "/send" && r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+	projectID := os.Getenv(EnvPubSubProject)
+	topicID := os.Getenv(EnvPubSubTopic)
+	if projectID == "" || topicID == "" {
+		return fmt.Errorf("missing required env vars: %s or %s", EnvPubSubProject, EnvPubSubTopic)
 	}
 
-	// --- 1. Load Config & Validate ---
-	delegatedUser := os.Getenv(EnvDelegatedUser)
+	q, err := queue.NewPubSubQueue(ctx, projectID, topicID)
+	if err != nil {
+		return err
+	}
+	sendQueue = q
+	return nil
+}
 
-	// --- 2. Parse Payload ---
-	var req email.Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Warn("invalid json payload", "error", err)
-		http.Error(w, "Bad Request", http.StatusBadRequest)
-		return
+// mintUnsubscribeURL signs a one-click unsubscribe token for recipient and
+// campaignID via the IAM Credentials client (initGmailService must have run
+// first) and returns the full /webhook/unsubscribe URL the List-Unsubscribe
+// header should point recipients at.
+func mintUnsubscribeURL(ctx context.Context, recipient, campaignID string) (string, error) {
+	baseURL := os.Getenv(EnvUnsubscribeURL)
+	if baseURL == "" {
+		return "", fmt.Errorf("missing required env var: %s", EnvUnsubscribeURL)
 	}
 
-	// --- 3. SAFETY BRAKES ---
+	functionSA := os.Getenv(EnvFunctionSA)
+	token, err := auth.SignUnsubscribeToken(ctx, iamClient, functionSA, recipient, campaignID)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign unsubscribe token: %v", err)
+	}
+
+	v := url.Values{}
+	v.Set("token", token)
+	return fmt.Sprintf("%s/webhook/unsubscribe?%s", strings.TrimSuffix(baseURL, "/"), v.Encode()), nil
+}
 
+// validateRequest runs the safety brakes shared by both entry points. It
+// returns a human-readable reason the request was rejected, or "" if it's
+// clear to send.
+func validateRequest(req email.Request, delegatedUser string) string {
 	// A. Loop Protection: Prevent sending TO the delegated user account
-	if req.RecipientAddress == delegatedUser {
-		logger.Warn("safety brake: blocked attempt to send to delegated user",
-			"recipient_address", req.RecipientAddress,
-			"delegated_user", delegatedUser,
-		)
-		http.Error(w, "Safety Block: Cannot send to delegated user account", http.StatusBadRequest)
-		return
+	if req.Recipient == delegatedUser {
+		return "Safety Block: Cannot send to delegated user account"
 	}
 
 	// B. Attachment Size Check (Approximate)
@@ -121,78 +209,152 @@ func HandleEmail(w http.ResponseWriter, r *http.Request) {
 	for _, att := range req.Attachments {
 		totalSize += len(att.ContentB64)
 	}
+	for _, img := range req.InlineImages {
+		totalSize += len(img.ContentB64)
+	}
 	// Check if size > ~26MB (allow some buffer for encoding overhead)
 	if float64(totalSize) > (float64(MaxTotalSizeMB) * 1024 * 1024 * 1.33) {
-		logger.Warn("safety brake: attachments too large", "size_bytes", totalSize)
-		http.Error(w, "Attachments exceed size limit", http.StatusBadRequest)
-		return
+		return "Attachments exceed size limit"
+	}
+
+	return ""
+}
+
+// PubSubMessage mirrors the "message" field of the Eventarc CloudEvent data
+// payload for a google.cloud.pubsub.topic.v1.messagePublished event.
+type PubSubMessage struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes"`
+	MessageID  string            `json:"messageId"`
+}
+
+// MessagePublishedData is the CloudEvent data payload Eventarc delivers for a
+// Pub/Sub topic publish.
+type MessagePublishedData struct {
+	Message PubSubMessage `json:"message"`
+}
+
+// HandleEmailEvent drains the send queue: it decodes a Pub/Sub-delivered
+// email.Request, skips it if already processed, and sends it with retries on
+// transient Gmail errors. Registering it separately from HandleEmail lets the
+// module be invoked from Eventarc, GCS notifications, or scheduled jobs that
+// publish onto the same topic.
+func HandleEmailEvent(ctx context.Context, evt cloudevents.Event) error {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	var data MessagePublishedData
+	if err := evt.DataAs(&data); err != nil {
+		logger.Error("invalid cloudevent payload", "error", err)
+		return fmt.Errorf("failed to decode cloudevent: %v", err)
+	}
+
+	var req email.Request
+	if err := json.Unmarshal(data.Message.Data, &req); err != nil {
+		logger.Error("invalid queued payload", "error", err)
+		return fmt.Errorf("failed to decode queued request: %v", err)
+	}
+
+	// --- Idempotency ---
+	key := data.Message.Attributes[queue.AttributeDedupeKey]
+	if key == "" {
+		key = queue.DedupeKey(req)
+	}
+	if dedupe.SeenOrMark(key) {
+		logger.Info("skipping duplicate delivery", "dedupe_key", key, "recipient", req.Recipient)
+		return nil
+	}
+
+	delegatedUser := os.Getenv(EnvDelegatedUser)
+	if reason := validateRequest(req, delegatedUser); reason != "" {
+		logger.Warn("safety brake: dropped queued request", "recipient", req.Recipient, "reason", reason)
+		return nil
+	}
+
+	suppressed, err := suppressor.IsSuppressed(ctx, req.Recipient)
+	if err != nil {
+		logger.Error("failed to check suppression list", "recipient", req.Recipient, "error", err)
+		dedupe.Unmark(key)
+		return err
+	}
+	if suppressed {
+		logger.Info("skipping suppressed recipient", "recipient", req.Recipient)
+		return nil
 	}
 
-	// --- 4. Initialize Service ---
 	if err := initGmailService(ctx); err != nil {
 		logger.Error("failed to init auth", "error", err)
-		http.Error(w, "Auth Configuration Error", http.StatusInternalServerError)
-		return
+		dedupe.Unmark(key)
+		return err
+	}
+
+	if req.Options.Unsubscribe != nil && req.Options.Unsubscribe.HTTPSURL == "" {
+		httpsURL, err := mintUnsubscribeURL(ctx, req.Recipient, req.CampaignID)
+		if err != nil {
+			logger.Error("failed to mint unsubscribe token", "recipient", req.Recipient, "error", err)
+			dedupe.Unmark(key)
+			return err
+		}
+		req.Options.Unsubscribe.HTTPSURL = httpsURL
 	}
 
-	// --- 5. Construct MIME Message ---
-	logger.Info("preparing email",
-		"sender_address", req.SenderAddress,
+	logger.Info("preparing queued email",
 		"sender_name", req.SenderName,
-		"recipient_address", req.RecipientAddress,
-		"recipient_name", req.RecipientName,
+		"recipient", req.Recipient,
+		"campaign", req.CampaignID,
 	)
 
 	rawMime, err := email.BuildMime(req)
 	if err != nil {
 		logger.Error("mime build failed", "error", err)
-		http.Error(w, "Message Build Error", http.StatusInternalServerError)
-		return
+		dedupe.Unmark(key)
+		return fmt.Errorf("mime build failed: %v", err)
 	}
+	msg := &gmail.Message{Raw: base64.URLEncoding.EncodeToString(rawMime)}
 
-	// --- 6. Send Email ---
-	msg := &gmail.Message{
-		Raw: base64.URLEncoding.EncodeToString(rawMime),
+	var sentMsg *gmail.Message
+	sendErr := queue.WithBackoff(ctx, queue.DefaultRetryConfig, func() error {
+		var err error
+		sentMsg, err = gmailService.Users.Messages.Send("me", msg).Do()
+		return err
+	})
+	if sendErr != nil {
+		logger.Error("upstream send failed", "recipient", req.Recipient, "error", sendErr)
+		dedupe.Unmark(key)
+		return fmt.Errorf("failed to send queued request: %v", sendErr)
 	}
 
-	sentMsg, err := gmailService.Users.Messages.Send("me", msg).Do()
-	if err != nil {
-		logger.Error("upstream send failed", "recipient_address", req.RecipientAddress, "error", err)
-		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
-		return
-	}
+	applyLabels(logger, sentMsg.Id, req.Options)
 
-	// --- 7. Post-Process: Labels ---
-	labelsToAdd := req.Options.LabelIDs
+	logger.Info("email sent successfully",
+		"id", sentMsg.Id,
+		"recipient", req.Recipient,
+		"campaign", req.CampaignID,
+	)
+	return nil
+}
+
+// applyLabels adds any requested labels to a sent message. Failures are
+// logged rather than propagated: the send itself already succeeded, and
+// retrying the whole message to fix a label would risk a duplicate send.
+func applyLabels(logger *slog.Logger, messageID string, opts email.Options) {
+	labelsToAdd := opts.LabelIDs
 	if labelsToAdd == nil {
 		labelsToAdd = []string{}
 	}
-
-	if req.Options.Starred {
+	if opts.Starred {
 		labelsToAdd = append(labelsToAdd, constants.LabelStarred)
 	}
-	if req.Options.Important {
+	if opts.Important {
 		labelsToAdd = append(labelsToAdd, constants.LabelImportant)
 	}
 
-	if len(labelsToAdd) > 0 {
-		_, err := gmailService.Users.Messages.Modify("me", sentMsg.Id, &gmail.ModifyMessageRequest{
-			AddLabelIds: labelsToAdd,
-		}).Do()
-		if err != nil {
-			logger.Warn("failed to apply labels", "id", sentMsg.Id, "labels", labelsToAdd, "error", err)
-		}
+	if len(labelsToAdd) == 0 {
+		return
 	}
 
-	// --- 8. Success Log ---
-	logger.Info("email sent successfully",
-		"id", sentMsg.Id,
-		"recipient_address", req.RecipientAddress,
-		"sender_address", req.SenderAddress,
-		"campaign", req.CampaignID,
-	)
-
-	w.Header().Set(constants.HTTPContentType, constants.HTTPAppJSON)
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"sent", "id":"%s"}`, sentMsg.Id)
+	if _, err := gmailService.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
+		AddLabelIds: labelsToAdd,
+	}).Do(); err != nil {
+		logger.Warn("failed to apply labels", "id", messageID, "labels", labelsToAdd, "error", err)
+	}
 }
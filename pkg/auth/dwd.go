@@ -13,6 +13,22 @@ import (
 	"google.golang.org/api/iamcredentials/v1"
 )
 
+const (
+	// DefaultTokenLifetime is used when KeylessTokenSource.Lifetime is unset,
+	// matching the previously-hardcoded 3600s exp.
+	DefaultTokenLifetime = time.Hour
+
+	// MinTokenLifetime and MaxTokenLifetime bound KeylessTokenSource.Lifetime.
+	// 300s is IAM's floor for minted tokens. The ceiling is capped at 1h
+	// because DWD-impersonated tokens are minted via SignJwt + token-exchange,
+	// and the token endpoint only honors SignJwt-minted JWTs for up to an
+	// hour regardless of the requested exp; IAM's GenerateAccessToken can
+	// mint longer-lived tokens, but has no "subject" claim and so cannot
+	// impersonate DelegateEmail, making it unusable for DWD.
+	MinTokenLifetime = 300 * time.Second
+	MaxTokenLifetime = time.Hour
+)
+
 // KeylessTokenSource implements oauth2.TokenSource.
 // It uses the GCP IAM Credentials API to sign a JWT for Domain-Wide Delegation,
 // avoiding the need for a local private key file.
@@ -28,23 +44,44 @@ type KeylessTokenSource struct {
 
 	// IamClient is the authenticated client capable of calling the IAM Credentials API
 	IamClient *iamcredentials.Service
+
+	// Lifetime is how long a minted token stays valid, bounded to
+	// [MinTokenLifetime, MaxTokenLifetime]. Zero means DefaultTokenLifetime.
+	Lifetime time.Duration
+}
+
+func (k *KeylessTokenSource) lifetime() time.Duration {
+	if k.Lifetime == 0 {
+		return DefaultTokenLifetime
+	}
+	return k.Lifetime
 }
 
 // Token satisfies the oauth2.TokenSource interface.
-// It handles the generation of the signed JWT and the exchange for an Access Token.
 func (k *KeylessTokenSource) Token() (*oauth2.Token, error) {
+	lifetime := k.lifetime()
+	if lifetime < MinTokenLifetime || lifetime > MaxTokenLifetime {
+		return nil, fmt.Errorf("token lifetime %s out of bounds [%s, %s]", lifetime, MinTokenLifetime, MaxTokenLifetime)
+	}
+
+	return k.signJwtAndExchange(lifetime)
+}
+
+// signJwtAndExchange handles the generation of the signed JWT and the
+// exchange for an Access Token.
+func (k *KeylessTokenSource) signJwtAndExchange(lifetime time.Duration) (*oauth2.Token, error) {
 	ctx := context.Background()
 
 	// 1. Construct the JWT Claim Set
 	// This mirrors the standard Google Service Account JWT format
 	iat := time.Now().Unix()
-	exp := iat + 3600 // Token valid for 1 hour
+	exp := iat + int64(lifetime.Seconds())
 
 	claims := map[string]interface{}{
-		constants.JWTClaimIssuer:    k.ServiceAccountEmail,
-		constants.JWTClaimSubject:   k.DelegateEmail, // The user we are impersonating
-		constants.JWTClaimScope:     k.Scopes,        // Permissions
-		constants.JWTClaimAudience:  constants.OAuth2TokenURL,
+		constants.JWTClaimIssuer:     k.ServiceAccountEmail,
+		constants.JWTClaimSubject:    k.DelegateEmail,          // The user we are impersonating
+		constants.JWTClaimScope:      k.Scopes,                 // Permissions
+		constants.JWTClaimAudience:   constants.OAuth2TokenURL, // must match the token-exchange endpoint posted to below
 		constants.JWTClaimExpiration: exp,
 		constants.JWTClaimIssuedAt:   iat,
 	}
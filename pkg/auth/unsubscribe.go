@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/iamcredentials/v1"
+
+	"github.com/vinm0/gmail-handler/pkg/constants"
+)
+
+// SignUnsubscribeToken produces a URL-safe token binding recipient and
+// campaignID, signed by serviceAccountEmail via IAM SignBlob so the
+// /webhook/unsubscribe route can verify it without holding a local signing
+// key.
+func SignUnsubscribeToken(ctx context.Context, iamClient *iamcredentials.Service, serviceAccountEmail, recipient, campaignID string) (string, error) {
+	payload := recipient + "|" + campaignID
+
+	sig, err := signBlob(ctx, iamClient, serviceAccountEmail, payload)
+	if err != nil {
+		return "", err
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encodedPayload + "." + sig, nil
+}
+
+// minSignedBlobLen and maxSignedBlobLen bound a decoded SignBlob signature:
+// IAM signs with a 2048-4096 bit RSA key, i.e. 256-512 raw bytes. Tokens
+// outside that range are rejected before the IAM round trip below, so a
+// flood of garbage tokens at this unauthenticated endpoint can't turn into a
+// flood of SignBlob calls.
+const (
+	minSignedBlobLen = 256
+	maxSignedBlobLen = 512
+)
+
+// VerifyUnsubscribeToken checks a token minted by SignUnsubscribeToken,
+// returning the recipient and campaign ID it was signed for. Structurally
+// invalid tokens are rejected locally; only a token that's at least
+// well-formed costs an IAM SignBlob call to verify.
+func VerifyUnsubscribeToken(ctx context.Context, iamClient *iamcredentials.Service, serviceAccountEmail, token string) (recipient, campaignID string, err error) {
+	encodedPayload, sig, ok := strings.Cut(token, ".")
+	if !ok || encodedPayload == "" || sig == "" {
+		return "", "", fmt.Errorf("malformed unsubscribe token")
+	}
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", fmt.Errorf("malformed unsubscribe token payload: %v", err)
+	}
+	recipient, campaignID, ok = strings.Cut(string(rawPayload), "|")
+	if !ok || recipient == "" {
+		return "", "", fmt.Errorf("malformed unsubscribe token payload")
+	}
+
+	sigBytes, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || len(sigBytes) < minSignedBlobLen || len(sigBytes) > maxSignedBlobLen {
+		return "", "", fmt.Errorf("malformed unsubscribe token signature")
+	}
+
+	expectedSig, err := signBlob(ctx, iamClient, serviceAccountEmail, string(rawPayload))
+	if err != nil {
+		return "", "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(expectedSig), []byte(sig)) != 1 {
+		return "", "", fmt.Errorf("unsubscribe token signature mismatch")
+	}
+	return recipient, campaignID, nil
+}
+
+// signBlob signs payload with serviceAccountEmail's key via IAM SignBlob,
+// returning a URL-safe base64 signature.
+func signBlob(ctx context.Context, iamClient *iamcredentials.Service, serviceAccountEmail, payload string) (string, error) {
+	name := fmt.Sprintf(constants.IAMServiceAccountPath, serviceAccountEmail)
+	resp, err := iamClient.Projects.ServiceAccounts.SignBlob(name, &iamcredentials.SignBlobRequest{
+		Payload: base64.StdEncoding.EncodeToString([]byte(payload)),
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign blob via IAM API: %v", err)
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(resp.SignedBlob)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode signed blob: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(sigBytes), nil
+}
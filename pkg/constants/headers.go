@@ -2,34 +2,44 @@ package constants
 
 // Email Header Keys
 const (
-	HeaderFrom           = "From"
-	HeaderTo             = "To"
-	HeaderCC             = "Cc"
-	HeaderBCC            = "Bcc"
-	HeaderReplyTo        = "Reply-To"
-	HeaderSubject        = "Subject"
-	HeaderMIMEVersion    = "MIME-Version"
-	HeaderDisposition    = "Content-Disposition"
-	HeaderTransferEnc    = "Content-Transfer-Encoding"
-	HeaderContentType    = "Content-Type"
-	HeaderReceipt        = "Disposition-Notification-To"
+	HeaderFrom          = "From"
+	HeaderTo            = "To"
+	HeaderCC            = "Cc"
+	HeaderBCC           = "Bcc"
+	HeaderReplyTo       = "Reply-To"
+	HeaderSubject       = "Subject"
+	HeaderMIMEVersion   = "MIME-Version"
+	HeaderDisposition   = "Content-Disposition"
+	HeaderTransferEnc   = "Content-Transfer-Encoding"
+	HeaderContentType   = "Content-Type"
+	HeaderReceipt       = "Disposition-Notification-To"
+	HeaderContentID     = "Content-ID"
+	HeaderListUnsub     = "List-Unsubscribe"
+	HeaderListUnsubPost = "List-Unsubscribe-Post"
 )
 
 // MIME & Content Formats
 const (
-	MimeMultipartMixed   = "multipart/mixed"
-	MimeTextHTML         = "text/html"
-	CharsetUTF8          = "charset=UTF-8"
-	EncodingBase64       = "base64"
-	MimeVer1             = "1.0"
+	MimeMultipartMixed       = "multipart/mixed"
+	MimeMultipartRelated     = "multipart/related"
+	MimeMultipartAlternative = "multipart/alternative"
+	MimeTextHTML             = "text/html"
+	MimeTextPlain            = "text/plain"
+	CharsetUTF8              = "charset=UTF-8"
+	EncodingBase64           = "base64"
+	MimeVer1                 = "1.0"
 )
 
 // Content Disposition Values
 const (
 	DispositionAttachment = "attachment"
-	// DispositionInline  = "inline" // Useful if you add inline images later
+	DispositionInline     = "inline"
 )
 
+// ListUnsubscribePostOneClick is the RFC 8058 List-Unsubscribe-Post value
+// mail clients POST back to trigger a one-click unsubscribe.
+const ListUnsubscribePostOneClick = "List-Unsubscribe=One-Click"
+
 // Gmail System Labels
 const (
 	LabelStarred   = "STARRED"
@@ -43,4 +53,5 @@ const (
 const (
 	HTTPContentType = "Content-Type"
 	HTTPAppJSON     = "application/json"
-)
\ No newline at end of file
+	HTTPTextHTML    = "text/html; charset=utf-8"
+)
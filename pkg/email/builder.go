@@ -3,6 +3,7 @@ package email
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"mime/multipart"
 	"net/textproto"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/microcosm-cc/bluemonday"
 
 	"github.com/vinm0/gmail-handler/pkg/constants"
+	"github.com/vinm0/gmail-handler/pkg/sanitizer"
 )
 
 type Request struct {
@@ -31,7 +33,8 @@ type Request struct {
 	Options Options `json:"options"`
 
 	// Assets
-	Attachments []Attachment `json:"attachments,omitempty"`
+	Attachments  []Attachment  `json:"attachments,omitempty"`
+	InlineImages []InlineAsset `json:"inline_images,omitempty"`
 
 	// Advanced / Technical
 	CustomHeaders map[string]string `json:"custom_headers,omitempty"` // e.g. {"List-Unsubscribe": "<...>"}
@@ -42,6 +45,18 @@ type Options struct {
 	ReadReceipt bool     `json:"request_read_receipt"`
 	LabelIDs    []string `json:"label_ids"` // Gmail Label IDs to apply
 	Important   bool     `json:"important"` // Explicit priority flag
+
+	// Unsubscribe, when set, emits RFC 8058 List-Unsubscribe headers.
+	Unsubscribe *UnsubscribeOptions `json:"unsubscribe,omitempty"`
+}
+
+// UnsubscribeOptions drives the List-Unsubscribe / List-Unsubscribe-Post
+// headers BuildMime emits so mailbox providers can surface a native
+// unsubscribe action, and, with OneClick, a one-click POST per RFC 8058.
+type UnsubscribeOptions struct {
+	MailtoAddress string `json:"mailto_address,omitempty"`
+	HTTPSURL      string `json:"https_url,omitempty"`
+	OneClick      bool   `json:"one_click,omitempty"`
 }
 
 type Attachment struct {
@@ -50,10 +65,120 @@ type Attachment struct {
 	MimeType   string `json:"mime_type"`
 }
 
-// SecurityPolicy returns the appropriate sanitizer based on the campaign
+// InlineAsset is an image referenced from BodyHTML via a "cid:" URL
+// (e.g. <img src="cid:logo">), delivered alongside the message instead of
+// hotlinked so it renders without the recipient fetching external content.
+type InlineAsset struct {
+	ContentID  string `json:"content_id"` // Referenced from BodyHTML as cid:<content_id>
+	Filename   string `json:"filename"`
+	MimeType   string `json:"mime_type"`
+	ContentB64 string `json:"content_b64"`
+}
+
+// SecurityPolicy returns the compiled bluemonday policy for campaignID, as
+// configured via SANITIZER_CONFIG, falling back to UGCPolicy for campaigns
+// with no entry there.
 func SecurityPolicy(campaignID string) *bluemonday.Policy {
-	// TODO: Implement campaign-specific policies if needed
-	return bluemonday.UGCPolicy()
+	return sanitizer.Default().Policy(campaignID)
+}
+
+// plainTextFromHTML derives a text/plain alternative from already-sanitized
+// HTML by stripping all markup, so the alternative can't reintroduce
+// anything SecurityPolicy stripped out.
+func plainTextFromHTML(safeHTML string) string {
+	stripped := bluemonday.StrictPolicy().Sanitize(safeHTML)
+	return html.UnescapeString(stripped)
+}
+
+// buildAlternativePart wraps a text/plain rendering and the sanitized HTML
+// body in a multipart/alternative part, so text-only clients (and spam
+// filters) see a plain-text rendering instead of just raw HTML.
+func buildAlternativePart(safeHTML string) (contentType string, body []byte, err error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	plainHeader := make(textproto.MIMEHeader)
+	plainHeader.Set(constants.HeaderContentType, fmt.Sprintf("%s; %s", constants.MimeTextPlain, constants.CharsetUTF8))
+	plainPart, err := writer.CreatePart(plainHeader)
+	if err != nil {
+		return "", nil, err
+	}
+	plainPart.Write([]byte(plainTextFromHTML(safeHTML)))
+
+	htmlHeader := make(textproto.MIMEHeader)
+	htmlHeader.Set(constants.HeaderContentType, fmt.Sprintf("%s; %s", constants.MimeTextHTML, constants.CharsetUTF8))
+	htmlPart, err := writer.CreatePart(htmlHeader)
+	if err != nil {
+		return "", nil, err
+	}
+	htmlPart.Write([]byte(safeHTML))
+
+	if err := writer.Close(); err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s; boundary=%s", constants.MimeMultipartAlternative, writer.Boundary()), buf.Bytes(), nil
+}
+
+// buildRelatedPart wraps the multipart/alternative part and any inline
+// images in a multipart/related part, so HTML clients can resolve
+// "cid:<ContentID>" references in BodyHTML to the inline parts.
+func buildRelatedPart(altType string, altBody []byte, inlineImages []InlineAsset) (contentType string, body []byte, err error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	altHeader := make(textproto.MIMEHeader)
+	altHeader.Set(constants.HeaderContentType, altType)
+	altPart, err := writer.CreatePart(altHeader)
+	if err != nil {
+		return "", nil, err
+	}
+	altPart.Write(altBody)
+
+	for _, img := range inlineImages {
+		imgHeader := make(textproto.MIMEHeader)
+		imgHeader.Set(constants.HeaderContentType, img.MimeType)
+		imgHeader.Set(constants.HeaderTransferEnc, constants.EncodingBase64)
+		imgHeader.Set(constants.HeaderDisposition, fmt.Sprintf("%s; filename=\"%s\"", constants.DispositionInline, img.Filename))
+		imgHeader.Set(constants.HeaderContentID, fmt.Sprintf("<%s>", img.ContentID))
+
+		imgPart, err := writer.CreatePart(imgHeader)
+		if err != nil {
+			return "", nil, err
+		}
+		cleanContent := strings.ReplaceAll(img.ContentB64, "\n", "")
+		imgPart.Write([]byte(cleanContent))
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s; boundary=%s", constants.MimeMultipartRelated, writer.Boundary()), buf.Bytes(), nil
+}
+
+// listUnsubscribeHeaders builds the RFC 8058 List-Unsubscribe and
+// List-Unsubscribe-Post headers from opts, or "" if opts is nil or has
+// neither address set.
+func listUnsubscribeHeaders(opts *UnsubscribeOptions) string {
+	if opts == nil {
+		return ""
+	}
+
+	var targets []string
+	if opts.MailtoAddress != "" {
+		targets = append(targets, fmt.Sprintf("<mailto:%s>", opts.MailtoAddress))
+	}
+	if opts.HTTPSURL != "" {
+		targets = append(targets, fmt.Sprintf("<%s>", opts.HTTPSURL))
+	}
+	if len(targets) == 0 {
+		return ""
+	}
+
+	headers := fmt.Sprintf("%s: %s\r\n", constants.HeaderListUnsub, strings.Join(targets, ", "))
+	if opts.OneClick {
+		headers += fmt.Sprintf("%s: %s\r\n", constants.HeaderListUnsubPost, constants.ListUnsubscribePostOneClick)
+	}
+	return headers
 }
 
 func BuildMime(req Request) ([]byte, error) {
@@ -108,6 +233,8 @@ func BuildMime(req Request) ([]byte, error) {
 		headers += fmt.Sprintf("%s: me\r\n", constants.HeaderReceipt)
 	}
 
+	headers += listUnsubscribeHeaders(req.Options.Unsubscribe)
+
 	// Custom Headers
 	for k, v := range req.CustomHeaders {
 		headers += formatAddr(k, v)
@@ -115,16 +242,23 @@ func BuildMime(req Request) ([]byte, error) {
 
 	b.WriteString(headers + "\r\n")
 
-	// 4. Write HTML Body
-	bodyHeader := make(textproto.MIMEHeader)
-	// "Content-Type", "text/html; charset=UTF-8"
-	bodyHeader.Set(constants.HeaderContentType, fmt.Sprintf("%s; %s", constants.MimeTextHTML, constants.CharsetUTF8))
+	// 4. Write Body: multipart/related(multipart/alternative(text/plain, text/html), inline images...)
+	altType, altBody, err := buildAlternativePart(safeBody)
+	if err != nil {
+		return nil, err
+	}
+	relatedType, relatedBody, err := buildRelatedPart(altType, altBody, req.InlineImages)
+	if err != nil {
+		return nil, err
+	}
 
-	bodyPart, err := writer.CreatePart(bodyHeader)
+	relatedHeader := make(textproto.MIMEHeader)
+	relatedHeader.Set(constants.HeaderContentType, relatedType)
+	relatedPart, err := writer.CreatePart(relatedHeader)
 	if err != nil {
 		return nil, err
 	}
-	bodyPart.Write([]byte(safeBody))
+	relatedPart.Write(relatedBody)
 
 	// 5. Write Attachments
 	for _, att := range req.Attachments {
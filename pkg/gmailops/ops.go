@@ -0,0 +1,190 @@
+package gmailops
+
+import (
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// gmailUser is the special value meaning "the authenticated (delegated)
+// user" for all gmail.Users.* calls.
+const gmailUser = "me"
+
+func toMessage(msg *gmail.Message) Message {
+	out := Message{
+		ID:       msg.Id,
+		ThreadID: msg.ThreadId,
+		LabelIDs: msg.LabelIds,
+		Snippet:  msg.Snippet,
+	}
+	if msg.Payload != nil && len(msg.Payload.Headers) > 0 {
+		out.Headers = make(map[string]string, len(msg.Payload.Headers))
+		for _, h := range msg.Payload.Headers {
+			out.Headers[h.Name] = h.Value
+		}
+	}
+	return out
+}
+
+func toMessageRef(msg *gmail.Message) MessageRef {
+	if msg == nil {
+		return MessageRef{}
+	}
+	return MessageRef{ID: msg.Id, ThreadID: msg.ThreadId}
+}
+
+// ListMessages wraps gmail.Users.Messages.List.
+func ListMessages(srv *gmail.Service, req ListMessagesRequest) (*ListMessagesResponse, error) {
+	call := srv.Users.Messages.List(gmailUser)
+	if req.Query != "" {
+		call = call.Q(req.Query)
+	}
+	if len(req.LabelIDs) > 0 {
+		call = call.LabelIds(req.LabelIDs...)
+	}
+	if req.MaxResults > 0 {
+		call = call.MaxResults(req.MaxResults)
+	}
+	if req.PageToken != "" {
+		call = call.PageToken(req.PageToken)
+	}
+
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %v", err)
+	}
+
+	out := &ListMessagesResponse{
+		NextPageToken:      resp.NextPageToken,
+		ResultSizeEstimate: resp.ResultSizeEstimate,
+	}
+	for _, m := range resp.Messages {
+		out.Messages = append(out.Messages, toMessageRef(m))
+	}
+	return out, nil
+}
+
+// GetMessage wraps gmail.Users.Messages.Get.
+func GetMessage(srv *gmail.Service, req GetMessageRequest) (*Message, error) {
+	format := req.Format
+	if format == "" {
+		format = "metadata"
+	}
+
+	msg, err := srv.Users.Messages.Get(gmailUser, req.ID).Format(format).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message %s: %v", req.ID, err)
+	}
+	out := toMessage(msg)
+	return &out, nil
+}
+
+// ModifyMessage wraps gmail.Users.Messages.Modify.
+func ModifyMessage(srv *gmail.Service, req ModifyMessageRequest) (*Message, error) {
+	msg, err := srv.Users.Messages.Modify(gmailUser, req.ID, &gmail.ModifyMessageRequest{
+		AddLabelIds:    req.AddLabelIDs,
+		RemoveLabelIds: req.RemoveLabelIDs,
+	}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to modify message %s: %v", req.ID, err)
+	}
+	out := toMessage(msg)
+	return &out, nil
+}
+
+// GetThread wraps gmail.Users.Threads.Get.
+func GetThread(srv *gmail.Service, req GetThreadRequest) (*Thread, error) {
+	format := req.Format
+	if format == "" {
+		format = "metadata"
+	}
+
+	thread, err := srv.Users.Threads.Get(gmailUser, req.ID).Format(format).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread %s: %v", req.ID, err)
+	}
+
+	out := &Thread{ID: thread.Id, Snippet: thread.Snippet}
+	for _, m := range thread.Messages {
+		out.Messages = append(out.Messages, toMessage(m))
+	}
+	return out, nil
+}
+
+// ListLabels wraps gmail.Users.Labels.List.
+func ListLabels(srv *gmail.Service) (*ListLabelsResponse, error) {
+	resp, err := srv.Users.Labels.List(gmailUser).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %v", err)
+	}
+
+	out := &ListLabelsResponse{}
+	for _, l := range resp.Labels {
+		out.Labels = append(out.Labels, Label{ID: l.Id, Name: l.Name, Type: l.Type})
+	}
+	return out, nil
+}
+
+// ListDrafts wraps gmail.Users.Drafts.List.
+func ListDrafts(srv *gmail.Service, req ListDraftsRequest) (*ListDraftsResponse, error) {
+	call := srv.Users.Drafts.List(gmailUser)
+	if req.MaxResults > 0 {
+		call = call.MaxResults(req.MaxResults)
+	}
+	if req.PageToken != "" {
+		call = call.PageToken(req.PageToken)
+	}
+
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %v", err)
+	}
+
+	out := &ListDraftsResponse{NextPageToken: resp.NextPageToken}
+	for _, d := range resp.Drafts {
+		draft := Draft{ID: d.Id}
+		if d.Message != nil {
+			draft.Message = toMessage(d.Message)
+		}
+		out.Drafts = append(out.Drafts, draft)
+	}
+	return out, nil
+}
+
+// ListHistory wraps gmail.Users.History.List.
+func ListHistory(srv *gmail.Service, req ListHistoryRequest) (*ListHistoryResponse, error) {
+	call := srv.Users.History.List(gmailUser).StartHistoryId(req.StartHistoryID)
+	if req.LabelID != "" {
+		call = call.LabelId(req.LabelID)
+	}
+	if req.PageToken != "" {
+		call = call.PageToken(req.PageToken)
+	}
+
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %v", err)
+	}
+
+	out := &ListHistoryResponse{
+		NextPageToken: resp.NextPageToken,
+		HistoryID:     resp.HistoryId,
+	}
+	for _, h := range resp.History {
+		rec := HistoryRecord{ID: h.Id}
+		for _, m := range h.MessagesAdded {
+			rec.MessagesAdded = append(rec.MessagesAdded, toMessageRef(m.Message))
+		}
+		for _, m := range h.MessagesDeleted {
+			rec.MessagesDeleted = append(rec.MessagesDeleted, toMessageRef(m.Message))
+		}
+		for _, m := range h.LabelsAdded {
+			rec.LabelsAdded = append(rec.LabelsAdded, toMessageRef(m.Message))
+		}
+		for _, m := range h.LabelsRemoved {
+			rec.LabelsRemoved = append(rec.LabelsRemoved, toMessageRef(m.Message))
+		}
+		out.History = append(out.History, rec)
+	}
+	return out, nil
+}
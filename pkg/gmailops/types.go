@@ -0,0 +1,118 @@
+// Package gmailops wraps the gmail.Users.* calls used by the HTTP inbox
+// automation routes. It mirrors the google.golang.org/api/gmail/v1 request
+// and response shapes, trimmed to the fields JSON callers actually need
+// instead of exposing googleapi's ServerResponse/ForceSendFields plumbing.
+package gmailops
+
+// MessageRef is the minimal handle returned from a list call, cheap enough to
+// enumerate without fetching each message body.
+type MessageRef struct {
+	ID       string `json:"id"`
+	ThreadID string `json:"thread_id"`
+}
+
+// Message is a trimmed view of gmail.Message: label state and headers,
+// without the raw/payload internals.
+type Message struct {
+	ID       string            `json:"id"`
+	ThreadID string            `json:"thread_id"`
+	LabelIDs []string          `json:"label_ids,omitempty"`
+	Snippet  string            `json:"snippet,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// Thread is a trimmed view of gmail.Thread.
+type Thread struct {
+	ID       string    `json:"id"`
+	Snippet  string    `json:"snippet,omitempty"`
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// Label is a trimmed view of gmail.Label.
+type Label struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// Draft is a trimmed view of gmail.Draft.
+type Draft struct {
+	ID      string  `json:"id"`
+	Message Message `json:"message"`
+}
+
+// HistoryRecord is a trimmed view of gmail.History.
+type HistoryRecord struct {
+	ID              uint64       `json:"id"`
+	MessagesAdded   []MessageRef `json:"messages_added,omitempty"`
+	MessagesDeleted []MessageRef `json:"messages_deleted,omitempty"`
+	LabelsAdded     []MessageRef `json:"labels_added,omitempty"`
+	LabelsRemoved   []MessageRef `json:"labels_removed,omitempty"`
+}
+
+// ListMessagesRequest mirrors gmail.Users.Messages.List's query parameters.
+type ListMessagesRequest struct {
+	Query      string   `json:"q,omitempty"`
+	LabelIDs   []string `json:"label_ids,omitempty"`
+	MaxResults int64    `json:"max_results,omitempty"`
+	PageToken  string   `json:"page_token,omitempty"`
+}
+
+// ListMessagesResponse mirrors gmail.ListMessagesResponse.
+type ListMessagesResponse struct {
+	Messages           []MessageRef `json:"messages,omitempty"`
+	NextPageToken      string       `json:"next_page_token,omitempty"`
+	ResultSizeEstimate int64        `json:"result_size_estimate,omitempty"`
+}
+
+// GetMessageRequest mirrors gmail.Users.Messages.Get's query parameters.
+type GetMessageRequest struct {
+	ID string `json:"id"`
+	// Format is one of "full", "metadata", "minimal", or "raw". Defaults to
+	// "metadata" -- the cheapest format that still includes headers.
+	Format string `json:"format,omitempty"`
+}
+
+// ModifyMessageRequest mirrors gmail.ModifyMessageRequest.
+type ModifyMessageRequest struct {
+	ID             string   `json:"id"`
+	AddLabelIDs    []string `json:"add_label_ids,omitempty"`
+	RemoveLabelIDs []string `json:"remove_label_ids,omitempty"`
+}
+
+// GetThreadRequest mirrors gmail.Users.Threads.Get's query parameters.
+type GetThreadRequest struct {
+	ID     string `json:"id"`
+	Format string `json:"format,omitempty"`
+}
+
+// ListLabelsResponse mirrors gmail.ListLabelsResponse.
+type ListLabelsResponse struct {
+	Labels []Label `json:"labels,omitempty"`
+}
+
+// ListDraftsRequest mirrors gmail.Users.Drafts.List's query parameters.
+type ListDraftsRequest struct {
+	MaxResults int64  `json:"max_results,omitempty"`
+	PageToken  string `json:"page_token,omitempty"`
+}
+
+// ListDraftsResponse mirrors gmail.ListDraftsResponse.
+type ListDraftsResponse struct {
+	Drafts        []Draft `json:"drafts,omitempty"`
+	NextPageToken string  `json:"next_page_token,omitempty"`
+}
+
+// ListHistoryRequest mirrors gmail.Users.History.List's query parameters.
+type ListHistoryRequest struct {
+	StartHistoryID uint64 `json:"start_history_id"`
+	LabelID        string `json:"label_id,omitempty"`
+	PageToken      string `json:"page_token,omitempty"`
+}
+
+// ListHistoryResponse mirrors gmail.ListHistoryResponse.
+type ListHistoryResponse struct {
+	History       []HistoryRecord `json:"history,omitempty"`
+	NextPageToken string          `json:"next_page_token,omitempty"`
+	HistoryID     uint64          `json:"history_id,omitempty"`
+}
@@ -0,0 +1,47 @@
+package queue
+
+import "sync"
+
+// Dedupe tracks which DedupeKeys have already been processed so that
+// Pub/Sub's at-least-once delivery doesn't result in duplicate sends.
+type Dedupe interface {
+	// SeenOrMark reports whether key has already been marked, atomically
+	// marking it if not, so concurrent deliveries of the same message can't
+	// race each other into sending twice.
+	SeenOrMark(key string) bool
+
+	// Unmark clears a key previously marked by SeenOrMark. Callers that mark
+	// a key before the send is confirmed must unmark it on any failure that
+	// asks for redelivery (a returned error), or a retry of a transient
+	// failure will be dropped as a false duplicate instead of resent.
+	Unmark(key string)
+}
+
+// InMemoryDedupe is a process-local Dedupe. It resets on cold start, which is
+// acceptable for Pub/Sub redelivery within a single warm instance; a
+// multi-instance deployment should back this with a shared store instead.
+type InMemoryDedupe struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewInMemoryDedupe returns an empty InMemoryDedupe.
+func NewInMemoryDedupe() *InMemoryDedupe {
+	return &InMemoryDedupe{seen: make(map[string]struct{})}
+}
+
+func (d *InMemoryDedupe) SeenOrMark(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = struct{}{}
+	return false
+}
+
+func (d *InMemoryDedupe) Unmark(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, key)
+}
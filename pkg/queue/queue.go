@@ -0,0 +1,71 @@
+// Package queue provides the async send path: HandleEmail enqueues onto a
+// durable backing store instead of calling Gmail inline, and HandleEmailEvent
+// drains it. This lets callers fire-and-forget large batches without blocking
+// on Gmail's per-user send quota.
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+
+	"github.com/vinm0/gmail-handler/pkg/email"
+)
+
+// AttributeDedupeKey is the Pub/Sub message attribute carrying the idempotency
+// key so HandleEmailEvent can dedupe without re-parsing the payload.
+const AttributeDedupeKey = "dedupe_key"
+
+// Queue enqueues an email.Request onto a durable backing store (Cloud Tasks or
+// Pub/Sub) for asynchronous delivery by the HandleEmailEvent entry point.
+type Queue interface {
+	Enqueue(ctx context.Context, req email.Request) error
+}
+
+// PubSubQueue publishes email.Request payloads to a Pub/Sub topic. An
+// Eventarc trigger (or a push subscription) is expected to invoke
+// HandleEmailEvent for each message published here.
+type PubSubQueue struct {
+	Topic *pubsub.Topic
+}
+
+// NewPubSubQueue creates a PubSubQueue bound to the given project and topic.
+func NewPubSubQueue(ctx context.Context, projectID, topicID string) (*PubSubQueue, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub client: %v", err)
+	}
+	return &PubSubQueue{Topic: client.Topic(topicID)}, nil
+}
+
+// Enqueue publishes req as JSON, tagged with its DedupeKey so subscribers can
+// filter re-deliveries without re-parsing the payload.
+func (q *PubSubQueue) Enqueue(ctx context.Context, req email.Request) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	result := q.Topic.Publish(ctx, &pubsub.Message{
+		Data: payload,
+		Attributes: map[string]string{
+			AttributeDedupeKey: DedupeKey(req),
+		},
+	})
+	if _, err := result.Get(ctx); err != nil {
+		return fmt.Errorf("failed to publish message: %v", err)
+	}
+	return nil
+}
+
+// DedupeKey derives a stable idempotency key from the fields that make a send
+// unique: the campaign, the recipient, and a content hash, so an edited retry
+// of the same campaign/recipient pair is treated as a distinct send.
+func DedupeKey(req email.Request) string {
+	h := sha256.Sum256([]byte(req.Subject + "\x00" + req.BodyHTML))
+	return fmt.Sprintf("%s:%s:%s", req.CampaignID, req.Recipient, hex.EncodeToString(h[:])[:16])
+}
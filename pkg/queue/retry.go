@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// RetryConfig controls the exponential backoff applied to retryable Gmail API
+// errors (HTTP 429 and 5xx) while draining the queue.
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryConfig backs off quickly at first: its 5 attempts sleep 0.5s,
+// 1s, 2s and 4s in between (7.5s in all, never reaching the 30s per-delay
+// cap), which comfortably fits inside a Cloud Function's request timeout.
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// Retryable reports whether err is a transient Gmail API error (429 or 5xx)
+// worth retrying with backoff.
+func Retryable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 429 || apiErr.Code >= 500
+	}
+	return false
+}
+
+// WithBackoff calls fn until it succeeds, fn's error stops being Retryable, or
+// cfg.MaxAttempts is exhausted, sleeping with exponential backoff in between.
+func WithBackoff(ctx context.Context, cfg RetryConfig, fn func() error) error {
+	delay := cfg.BaseDelay
+	var err error
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if !Retryable(err) || attempt == cfg.MaxAttempts {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+		if delay > cfg.MaxDelay {
+			delay = cfg.MaxDelay
+		}
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+// Package sanitizer builds and caches per-campaign bluemonday.Policy values
+// from an allowlist config, so different campaigns can permit different HTML
+// (e.g. richer marketing templates) without loosening the default policy for
+// everyone else.
+package sanitizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CampaignPolicy describes the allowlist bluemonday.Policy to compile for one
+// campaign ID.
+type CampaignPolicy struct {
+	Elements []string `json:"elements,omitempty" yaml:"elements,omitempty"`
+	// Attributes maps an element name to the attributes allowed on it.
+	Attributes         map[string][]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	URLSchemes         []string            `json:"url_schemes,omitempty" yaml:"url_schemes,omitempty"`
+	AllowStyling       bool                `json:"allow_styling,omitempty" yaml:"allow_styling,omitempty"`
+	StyleProperties    []string            `json:"style_properties,omitempty" yaml:"style_properties,omitempty"`
+	AllowDataURIImages bool                `json:"allow_data_uri_images,omitempty" yaml:"allow_data_uri_images,omitempty"`
+}
+
+// Config maps campaign IDs to their CampaignPolicy. Campaigns with no entry
+// fall back to bluemonday.UGCPolicy.
+type Config struct {
+	Campaigns map[string]CampaignPolicy `json:"campaigns" yaml:"campaigns"`
+}
+
+// LoadConfig reads a Config from path, parsing it as YAML if the extension is
+// ".yaml"/".yml" and as JSON otherwise.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sanitizer config %s: %v", path, err)
+	}
+
+	var cfg Config
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		err = yaml.Unmarshal(raw, &cfg)
+	} else {
+		err = json.Unmarshal(raw, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sanitizer config %s: %v", path, err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,38 @@
+package sanitizer
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// EnvConfigPath names the environment variable pointing at the sanitizer
+// config file (JSON or YAML) that maps campaign IDs to allowlist policies.
+const EnvConfigPath = "SANITIZER_CONFIG"
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+)
+
+// Default lazily loads the Registry named by EnvConfigPath, falling back to
+// an empty Registry (UGCPolicy for every campaign) if it's unset or fails to
+// load.
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		path := os.Getenv(EnvConfigPath)
+		if path == "" {
+			defaultRegistry = NewRegistry(nil)
+			return
+		}
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			slog.Warn("failed to load sanitizer config, falling back to UGCPolicy", "path", path, "error", err)
+			defaultRegistry = NewRegistry(nil)
+			return
+		}
+		defaultRegistry = NewRegistry(cfg)
+	})
+	return defaultRegistry
+}
@@ -0,0 +1,86 @@
+package sanitizer
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Registry compiles and caches a *bluemonday.Policy per campaign ID, falling
+// back to bluemonday.UGCPolicy for any campaign with no entry in its Config.
+type Registry struct {
+	cfg *Config
+
+	mu       sync.Mutex
+	policies map[string]*bluemonday.Policy
+}
+
+// NewRegistry builds a Registry from cfg. A nil cfg is valid and makes every
+// campaign fall back to UGCPolicy.
+func NewRegistry(cfg *Config) *Registry {
+	return &Registry{cfg: cfg, policies: make(map[string]*bluemonday.Policy)}
+}
+
+// Policy returns the compiled policy for campaignID, building and caching it
+// on first use.
+func (r *Registry) Policy(campaignID string) *bluemonday.Policy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.policies[campaignID]; ok {
+		return p
+	}
+
+	p := bluemonday.UGCPolicy()
+	if r.cfg != nil {
+		if cp, ok := r.cfg.Campaigns[campaignID]; ok {
+			p = buildPolicy(cp)
+		}
+	}
+	r.policies[campaignID] = p
+	return p
+}
+
+// Validate sanitizes html with campaignID's policy and reports whether the
+// policy changed it at all (stripped or rewrote anything, e.g. an added
+// rel="nofollow"), so a caller can preview what a send would remove before
+// actually sending.
+func (r *Registry) Validate(campaignID, html string) (sanitized string, stripped bool) {
+	sanitized = r.Policy(campaignID).Sanitize(html)
+	return sanitized, sanitized != html
+}
+
+// buildPolicy compiles a CampaignPolicy into a bluemonday.Policy.
+func buildPolicy(cp CampaignPolicy) *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	if len(cp.Elements) > 0 {
+		p.AllowElements(cp.Elements...)
+	}
+	for element, attrs := range cp.Attributes {
+		if len(attrs) == 0 {
+			continue
+		}
+		p.AllowAttrs(attrs...).OnElements(element)
+	}
+	if len(cp.URLSchemes) > 0 {
+		p.AllowURLSchemes(cp.URLSchemes...)
+	}
+	if cp.AllowStyling {
+		p.AllowStyling()
+	}
+	if len(cp.StyleProperties) > 0 {
+		spb := p.AllowStyles(cp.StyleProperties...)
+		if len(cp.Elements) > 0 {
+			spb.OnElements(cp.Elements...)
+		} else {
+			spb.OnElementsMatching(regexp.MustCompile(".+"))
+		}
+	}
+	if cp.AllowDataURIImages {
+		p.AllowDataURIImages()
+	}
+
+	return p
+}
@@ -0,0 +1,44 @@
+// Package suppress tracks recipients who have opted out via one-click
+// unsubscribe, so HandleEmail can drop them before a send ever reaches
+// Gmail's API.
+package suppress
+
+import (
+	"context"
+	"sync"
+)
+
+// Suppressor records and checks recipient opt-outs.
+type Suppressor interface {
+	// IsSuppressed reports whether recipient has opted out.
+	IsSuppressed(ctx context.Context, recipient string) (bool, error)
+	// Suppress records that recipient has opted out.
+	Suppress(ctx context.Context, recipient string) error
+}
+
+// InMemory is a process-local Suppressor. It resets on cold start, so a
+// multi-instance deployment should back this with Firestore or Redis instead
+// so opt-outs persist across instances and warm starts.
+type InMemory struct {
+	mu         sync.Mutex
+	suppressed map[string]struct{}
+}
+
+// NewInMemory returns an empty InMemory suppressor.
+func NewInMemory() *InMemory {
+	return &InMemory{suppressed: make(map[string]struct{})}
+}
+
+func (s *InMemory) IsSuppressed(ctx context.Context, recipient string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.suppressed[recipient]
+	return ok, nil
+}
+
+func (s *InMemory) Suppress(ctx context.Context, recipient string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suppressed[recipient] = struct{}{}
+	return nil
+}
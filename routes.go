@@ -0,0 +1,368 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/vinm0/gmail-handler/pkg/auth"
+	"github.com/vinm0/gmail-handler/pkg/constants"
+	"github.com/vinm0/gmail-handler/pkg/email"
+	"github.com/vinm0/gmail-handler/pkg/gmailops"
+	"github.com/vinm0/gmail-handler/pkg/sanitizer"
+)
+
+// newRouter builds the HTTP route table: /send enqueues outbound mail, and
+// the gmailops-backed routes proxy straight through to the Gmail API for
+// inbox automation (listing/reading messages and threads, managing labels
+// and drafts, and syncing history).
+func newRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/send", handleSend)
+	mux.HandleFunc("/", handleRoot)
+	mux.HandleFunc("/messages/list", handleMessagesList)
+	mux.HandleFunc("/messages/get", handleMessagesGet)
+	mux.HandleFunc("/messages/modify", handleMessagesModify)
+	mux.HandleFunc("/threads/get", handleThreadsGet)
+	mux.HandleFunc("/labels", handleLabels)
+	mux.HandleFunc("/drafts", handleDrafts)
+	mux.HandleFunc("/history", handleHistory)
+	mux.HandleFunc("/policies/validate", handlePoliciesValidate)
+	mux.HandleFunc("/webhook/unsubscribe", handleWebhookUnsubscribe)
+	return mux
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(constants.HTTPContentType, constants.HTTPAppJSON)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"status":"healthy","service":"gmail-handler"}`)
+}
+
+// handleRoot preserves "/" as an alias for "/send". http.ServeMux treats "/"
+// as the catch-all for any unregistered path, so anything other than an
+// exact "/" still has to 404 instead of silently falling through to
+// handleSend.
+func handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	handleSend(w, r)
+}
+
+// handleSend validates and enqueues a send request. The actual Gmail API
+// call happens asynchronously in HandleEmailEvent, so large batches don't
+// block on Gmail's per-user send quota.
+func handleSend(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	delegatedUser := os.Getenv(EnvDelegatedUser)
+
+	var req email.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("invalid json payload", "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if reason := validateRequest(req, delegatedUser); reason != "" {
+		logger.Warn("safety brake: rejected request",
+			"recipient", req.Recipient,
+			"reason", reason,
+		)
+		http.Error(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	suppressed, err := suppressor.IsSuppressed(ctx, req.Recipient)
+	if err != nil {
+		logger.Error("failed to check suppression list", "recipient", req.Recipient, "error", err)
+		http.Error(w, "Suppression Check Error", http.StatusInternalServerError)
+		return
+	}
+	if suppressed {
+		logger.Warn("safety brake: recipient unsubscribed", "recipient", req.Recipient)
+		http.Error(w, "Recipient has unsubscribed", http.StatusBadRequest)
+		return
+	}
+
+	if err := initSendQueue(ctx); err != nil {
+		logger.Error("failed to init send queue", "error", err)
+		http.Error(w, "Queue Configuration Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sendQueue.Enqueue(ctx, req); err != nil {
+		logger.Error("failed to enqueue request", "recipient", req.Recipient, "error", err)
+		http.Error(w, "Queue Error", http.StatusBadGateway)
+		return
+	}
+
+	logger.Info("email enqueued",
+		"recipient", req.Recipient,
+		"campaign", req.CampaignID,
+	)
+
+	w.Header().Set(constants.HTTPContentType, constants.HTTPAppJSON)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, `{"status":"queued"}`)
+}
+
+// requireGmailService lazily initializes gmailService for the gmailops
+// routes, writing an error response and returning false if it can't.
+func requireGmailService(w http.ResponseWriter, r *http.Request, logger *slog.Logger) bool {
+	if err := initGmailService(r.Context()); err != nil {
+		logger.Error("failed to init auth", "error", err)
+		http.Error(w, "Auth Configuration Error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(constants.HTTPContentType, constants.HTTPAppJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func handleMessagesList(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	var req gmailops.ListMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := gmailops.ListMessages(gmailService, req)
+	if err != nil {
+		logger.Error("messages.list failed", "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleMessagesGet(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	var req gmailops.GetMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := gmailops.GetMessage(gmailService, req)
+	if err != nil {
+		logger.Error("messages.get failed", "id", req.ID, "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleMessagesModify(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	var req gmailops.ModifyMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := gmailops.ModifyMessage(gmailService, req)
+	if err != nil {
+		logger.Error("messages.modify failed", "id", req.ID, "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleThreadsGet(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	var req gmailops.GetThreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := gmailops.GetThread(gmailService, req)
+	if err != nil {
+		logger.Error("threads.get failed", "id", req.ID, "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleLabels(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	resp, err := gmailops.ListLabels(gmailService)
+	if err != nil {
+		logger.Error("labels.list failed", "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleDrafts(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	var req gmailops.ListDraftsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := gmailops.ListDrafts(gmailService, req)
+	if err != nil {
+		logger.Error("drafts.list failed", "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// policiesValidateRequest is a submitted HTML sample to preview a campaign's
+// sanitizer policy against before actually sending.
+type policiesValidateRequest struct {
+	CampaignID string `json:"campaign_id"`
+	HTML       string `json:"html"`
+}
+
+// policiesValidateResponse returns the sanitized HTML alongside the
+// original, so the caller can diff them client-side to see what a send
+// through this campaign's policy would strip.
+type policiesValidateResponse struct {
+	CampaignID    string `json:"campaign_id"`
+	OriginalHTML  string `json:"original_html"`
+	SanitizedHTML string `json:"sanitized_html"`
+	Stripped      bool   `json:"stripped"`
+}
+
+func handlePoliciesValidate(w http.ResponseWriter, r *http.Request) {
+	var req policiesValidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	sanitizedHTML, stripped := sanitizer.Default().Validate(req.CampaignID, req.HTML)
+	writeJSON(w, http.StatusOK, policiesValidateResponse{
+		CampaignID:    req.CampaignID,
+		OriginalHTML:  req.HTML,
+		SanitizedHTML: sanitizedHTML,
+		Stripped:      stripped,
+	})
+}
+
+// handleWebhookUnsubscribe verifies the IAM SignBlob-signed token embedded in the
+// List-Unsubscribe URL and records the recipient as suppressed. RFC 8058
+// one-click unsubscribe requires the state change to happen only on POST
+// (mail-client/link-scanner GETs of the URL must be side-effect free), so GET
+// only validates the token and renders a confirmation page; POST is what
+// actually suppresses the recipient.
+func handleWebhookUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// FormValue covers both the query string (the List-Unsubscribe URL) and
+	// a POST body (the
+	// confirmation page's submit and RFC 8058 one-click clients that POST
+	// the token in the body instead of the URL).
+	token := r.FormValue("token")
+	if token == "" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if err := initIAMClient(ctx); err != nil {
+		logger.Error("failed to init iam client", "error", err)
+		http.Error(w, "Auth Configuration Error", http.StatusInternalServerError)
+		return
+	}
+
+	functionSA := os.Getenv(EnvFunctionSA)
+	recipient, campaignID, err := auth.VerifyUnsubscribeToken(ctx, iamClient, functionSA, token)
+	if err != nil {
+		logger.Warn("rejected unsubscribe token", "error", err)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		logger.Info("rendered unsubscribe confirmation", "recipient", recipient, "campaign", campaignID)
+		w.Header().Set(constants.HTTPContentType, constants.HTTPTextHTML)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `<html><body>
+<p>You're about to unsubscribe %s from this mailing list.</p>
+<form method="POST" action="/webhook/unsubscribe">
+<input type="hidden" name="token" value="%s">
+<button type="submit">Unsubscribe</button>
+</form>
+</body></html>`, html.EscapeString(recipient), html.EscapeString(token))
+		return
+	}
+
+	if err := suppressor.Suppress(ctx, recipient); err != nil {
+		logger.Error("failed to record suppression", "recipient", recipient, "campaign", campaignID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("recorded unsubscribe", "recipient", recipient, "campaign", campaignID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if !requireGmailService(w, r, logger) {
+		return
+	}
+
+	var req gmailops.ListHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := gmailops.ListHistory(gmailService, req)
+	if err != nil {
+		logger.Error("history.list failed", "error", err)
+		http.Error(w, fmt.Sprintf("Upstream API Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}